@@ -0,0 +1,293 @@
+// Package cache stores scraped opensource.apple.com pages and downloaded
+// tarballs on disk, keyed by URL and fetch time, so osac can revalidate
+// against the origin cheaply and can serve a snapshot of an earlier state
+// once a release has been removed or renamed upstream.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Cache is a directory on disk holding cached pages and tarballs.
+type Cache struct {
+	Dir string
+}
+
+// DefaultDir returns the default cache location, ~/.cache/osac.
+func DefaultDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cache: couldn't determine home dir: %w", err)
+	}
+	return filepath.Join(home, ".cache", "osac"), nil
+}
+
+// Open creates dir if necessary and returns a Cache rooted there.
+func Open(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("cache: couldn't create %s: %w", dir, err)
+	}
+	return &Cache{Dir: dir}, nil
+}
+
+func urlHash(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return hex.EncodeToString(sum[:])
+}
+
+// PageEntry records one fetch of a page URL.
+type PageEntry struct {
+	URL          string    `json:"url"`
+	Timestamp    time.Time `json:"timestamp"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	File         string    `json:"file"`
+}
+
+func (c *Cache) pageBucket(url string) string {
+	return filepath.Join(c.Dir, "pages", urlHash(url))
+}
+
+func (c *Cache) pageIndexPath(url string) string {
+	return filepath.Join(c.pageBucket(url), "index.json")
+}
+
+// PageEntries returns every cached fetch of url, in the order they were
+// stored, or nil if nothing has been cached yet.
+func (c *Cache) PageEntries(url string) ([]PageEntry, error) {
+	data, err := os.ReadFile(c.pageIndexPath(url))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cache: couldn't read index for %s: %w", url, err)
+	}
+	var entries []PageEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("cache: couldn't parse index for %s: %w", url, err)
+	}
+	return entries, nil
+}
+
+// StorePage records a fetch of url at time t and returns the new entry.
+func (c *Cache) StorePage(url string, body []byte, etag, lastModified string, t time.Time) (*PageEntry, error) {
+	bucket := c.pageBucket(url)
+	if err := os.MkdirAll(bucket, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("cache: couldn't create bucket for %s: %w", url, err)
+	}
+	entry := PageEntry{
+		URL:          url,
+		Timestamp:    t,
+		ETag:         etag,
+		LastModified: lastModified,
+		File:         fmt.Sprintf("%d.html", t.Unix()),
+	}
+	if err := os.WriteFile(filepath.Join(bucket, entry.File), body, 0644); err != nil {
+		return nil, fmt.Errorf("cache: couldn't write page for %s: %w", url, err)
+	}
+	entries, err := c.PageEntries(url)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, entry)
+	return &entry, c.writePageIndex(url, entries)
+}
+
+// RecordRevalidation appends an entry at time t that reuses the body of the
+// most recent entry, for the case where a conditional GET came back 304.
+func (c *Cache) RecordRevalidation(url string, t time.Time) (*PageEntry, error) {
+	latest, err := c.LatestPage(url)
+	if err != nil {
+		return nil, err
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("cache: no prior entry for %s to revalidate", url)
+	}
+	entry := *latest
+	entry.Timestamp = t
+	entries, err := c.PageEntries(url)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, entry)
+	return &entry, c.writePageIndex(url, entries)
+}
+
+func (c *Cache) writePageIndex(url string, entries []PageEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cache: couldn't encode index for %s: %w", url, err)
+	}
+	if err := os.WriteFile(c.pageIndexPath(url), data, 0644); err != nil {
+		return fmt.Errorf("cache: couldn't write index for %s: %w", url, err)
+	}
+	return nil
+}
+
+// ReadPage returns the cached body for e.
+func (c *Cache) ReadPage(e PageEntry) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(c.pageBucket(e.URL), e.File))
+	if err != nil {
+		return nil, fmt.Errorf("cache: couldn't read %s: %w", e.File, err)
+	}
+	return data, nil
+}
+
+// LatestPage returns the most recently stored entry for url, or nil if
+// nothing is cached.
+func (c *Cache) LatestPage(url string) (*PageEntry, error) {
+	entries, err := c.PageEntries(url)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	latest := entries[0]
+	for _, e := range entries[1:] {
+		if e.Timestamp.After(latest.Timestamp) {
+			latest = e
+		}
+	}
+	return &latest, nil
+}
+
+// ClosestPage returns the cached entry for url nearest to, and not after, t.
+// If every entry is after t, the earliest entry is returned instead so a
+// snapshot request never comes back empty when at least one fetch exists.
+func (c *Cache) ClosestPage(url string, t time.Time) (*PageEntry, error) {
+	entries, err := c.PageEntries(url)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("cache: no cached entries for %s", url)
+	}
+	var best *PageEntry
+	for i := range entries {
+		e := entries[i]
+		if e.Timestamp.After(t) {
+			continue
+		}
+		if best == nil || e.Timestamp.After(best.Timestamp) {
+			best = &entries[i]
+		}
+	}
+	if best == nil {
+		best = &entries[0]
+		for i := range entries {
+			if entries[i].Timestamp.Before(best.Timestamp) {
+				best = &entries[i]
+			}
+		}
+	}
+	return best, nil
+}
+
+// TarballEntry records one downloaded tarball for a package URL.
+type TarballEntry struct {
+	URL         string    `json:"url"`
+	ContentHash string    `json:"content_hash"`
+	Timestamp   time.Time `json:"timestamp"`
+	File        string    `json:"file"`
+}
+
+func (c *Cache) tarballBucket(url string) string {
+	return filepath.Join(c.Dir, "tarballs", urlHash(url))
+}
+
+func (c *Cache) tarballIndexPath(url string) string {
+	return filepath.Join(c.tarballBucket(url), "index.json")
+}
+
+// TarballEntries returns every cached download of url.
+func (c *Cache) TarballEntries(url string) ([]TarballEntry, error) {
+	data, err := os.ReadFile(c.tarballIndexPath(url))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cache: couldn't read tarball index for %s: %w", url, err)
+	}
+	var entries []TarballEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("cache: couldn't parse tarball index for %s: %w", url, err)
+	}
+	return entries, nil
+}
+
+// LatestTarball returns the most recently cached download of url, if any.
+func (c *Cache) LatestTarball(url string) (*TarballEntry, error) {
+	entries, err := c.TarballEntries(url)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	latest := entries[0]
+	for _, e := range entries[1:] {
+		if e.Timestamp.After(latest.Timestamp) {
+			latest = e
+		}
+	}
+	return &latest, nil
+}
+
+// TarballPath returns the path a cached tarball for e would live at.
+func (c *Cache) TarballPath(e TarballEntry) string {
+	return filepath.Join(c.tarballBucket(e.URL), e.File)
+}
+
+// StoreTarballFile hashes the file at path and copies it into the cache
+// under its content hash, recording a new entry for url at time t. If a
+// tarball with the same content hash is already cached, the copy is
+// skipped and the existing file is reused.
+func (c *Cache) StoreTarballFile(url, path string, t time.Time) (*TarballEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cache: couldn't open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("cache: couldn't hash %s: %w", path, err)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	bucket := c.tarballBucket(url)
+	if err := os.MkdirAll(bucket, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("cache: couldn't create bucket for %s: %w", url, err)
+	}
+	entry := TarballEntry{URL: url, ContentHash: hash, Timestamp: t, File: hash + ".tar.gz"}
+	dest := c.TarballPath(entry)
+	if _, err := os.Stat(dest); os.IsNotExist(err) {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("cache: couldn't rewind %s: %w", path, err)
+		}
+		out, err := os.Create(dest)
+		if err != nil {
+			return nil, fmt.Errorf("cache: couldn't create %s: %w", dest, err)
+		}
+		defer out.Close()
+		if _, err := io.Copy(out, f); err != nil {
+			return nil, fmt.Errorf("cache: couldn't copy %s: %w", dest, err)
+		}
+	}
+
+	entries, err := c.TarballEntries(url)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, entry)
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("cache: couldn't encode tarball index for %s: %w", url, err)
+	}
+	if err := os.WriteFile(c.tarballIndexPath(url), data, 0644); err != nil {
+		return nil, fmt.Errorf("cache: couldn't write tarball index for %s: %w", url, err)
+	}
+	return &entry, nil
+}
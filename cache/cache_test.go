@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestCache(t *testing.T) *Cache {
+	t.Helper()
+	c, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return c
+}
+
+func TestStoreAndLatestPage(t *testing.T) {
+	c := openTestCache(t)
+	url := "https://opensource.apple.com/"
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	if _, err := c.StorePage(url, []byte("old"), "etag-old", "", t0); err != nil {
+		t.Fatalf("StorePage(t0): %v", err)
+	}
+	if _, err := c.StorePage(url, []byte("new"), "etag-new", "", t1); err != nil {
+		t.Fatalf("StorePage(t1): %v", err)
+	}
+
+	latest, err := c.LatestPage(url)
+	if err != nil {
+		t.Fatalf("LatestPage: %v", err)
+	}
+	if latest == nil || !latest.Timestamp.Equal(t1) {
+		t.Fatalf("got %+v, want the t1 entry", latest)
+	}
+	body, err := c.ReadPage(*latest)
+	if err != nil {
+		t.Fatalf("ReadPage: %v", err)
+	}
+	if string(body) != "new" {
+		t.Fatalf("got body %q, want %q", body, "new")
+	}
+}
+
+func TestClosestPage(t *testing.T) {
+	c := openTestCache(t)
+	url := "https://opensource.apple.com/release/10.15.html"
+	jan := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	jun := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	dec := time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, ts := range []time.Time{jan, jun, dec} {
+		if _, err := c.StorePage(url, []byte(ts.String()), "", "", ts); err != nil {
+			t.Fatalf("StorePage(%v): %v", ts, err)
+		}
+	}
+
+	// A query between entries picks the closest one not after it.
+	got, err := c.ClosestPage(url, time.Date(2020, 7, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("ClosestPage: %v", err)
+	}
+	if !got.Timestamp.Equal(jun) {
+		t.Fatalf("got %v, want jun", got.Timestamp)
+	}
+
+	// A query before every entry falls back to the earliest one instead of
+	// coming back empty.
+	got, err = c.ClosestPage(url, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("ClosestPage: %v", err)
+	}
+	if !got.Timestamp.Equal(jan) {
+		t.Fatalf("got %v, want jan (earliest fallback)", got.Timestamp)
+	}
+}
+
+func TestClosestPageNoEntries(t *testing.T) {
+	c := openTestCache(t)
+	if _, err := c.ClosestPage("https://opensource.apple.com/nope.html", time.Now()); err == nil {
+		t.Fatal("expected an error when nothing has been cached for url")
+	}
+}
+
+func TestRecordRevalidation(t *testing.T) {
+	c := openTestCache(t)
+	url := "https://opensource.apple.com/"
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := time.Date(2020, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	if _, err := c.StorePage(url, []byte("body"), "etag-1", "", t0); err != nil {
+		t.Fatalf("StorePage: %v", err)
+	}
+	entry, err := c.RecordRevalidation(url, t1)
+	if err != nil {
+		t.Fatalf("RecordRevalidation: %v", err)
+	}
+	if entry.ETag != "etag-1" {
+		t.Fatalf("got ETag %q, want it carried over from the prior entry", entry.ETag)
+	}
+	if !entry.Timestamp.Equal(t1) {
+		t.Fatalf("got Timestamp %v, want %v", entry.Timestamp, t1)
+	}
+
+	body, err := c.ReadPage(*entry)
+	if err != nil {
+		t.Fatalf("ReadPage: %v", err)
+	}
+	if string(body) != "body" {
+		t.Fatalf("got body %q, want the reused body from the prior fetch", body)
+	}
+
+	entries, err := c.PageEntries(url)
+	if err != nil {
+		t.Fatalf("PageEntries: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2 (original + revalidation)", len(entries))
+	}
+}
+
+func TestRecordRevalidationWithoutPriorEntry(t *testing.T) {
+	c := openTestCache(t)
+	if _, err := c.RecordRevalidation("https://opensource.apple.com/nope.html", time.Now()); err == nil {
+		t.Fatal("expected an error revalidating a URL with no prior entry")
+	}
+}
+
+func TestStoreTarballFileDedupesByContent(t *testing.T) {
+	c := openTestCache(t)
+	src := filepath.Join(t.TempDir(), "xnu-1234.tar.gz")
+	if err := os.WriteFile(src, []byte("tarball contents"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	url := "https://opensource.apple.com/tarballs/xnu/xnu-1234.tar.gz"
+	t0 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	first, err := c.StoreTarballFile(url, src, t0)
+	if err != nil {
+		t.Fatalf("StoreTarballFile(t0): %v", err)
+	}
+	second, err := c.StoreTarballFile(url, src, t1)
+	if err != nil {
+		t.Fatalf("StoreTarballFile(t1): %v", err)
+	}
+	if first.ContentHash != second.ContentHash {
+		t.Fatalf("got different content hashes for identical content: %q vs %q", first.ContentHash, second.ContentHash)
+	}
+	if c.TarballPath(*first) != c.TarballPath(*second) {
+		t.Fatalf("expected both entries to share one on-disk file")
+	}
+
+	entries, err := c.TarballEntries(url)
+	if err != nil {
+		t.Fatalf("TarballEntries: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2 (one per StoreTarballFile call)", len(entries))
+	}
+
+	latest, err := c.LatestTarball(url)
+	if err != nil {
+		t.Fatalf("LatestTarball: %v", err)
+	}
+	if !latest.Timestamp.Equal(t1) {
+		t.Fatalf("got %v, want the t1 entry", latest.Timestamp)
+	}
+
+	data, err := os.ReadFile(c.TarballPath(*latest))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != "tarball contents" {
+		t.Fatalf("got %q, want the original tarball contents", data)
+	}
+}
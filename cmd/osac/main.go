@@ -0,0 +1,419 @@
+// Command osac is a CLI for browsing and downloading packages from
+// opensource.apple.com. It is a thin wrapper around the pkg/osac library,
+// the downloader package, the on-disk cache, and the deps resolver.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alexmullins/osac/cache"
+	"github.com/alexmullins/osac/deps"
+	"github.com/alexmullins/osac/downloader"
+	"github.com/alexmullins/osac/pkg/osac"
+	"github.com/alexmullins/osac/transport"
+)
+
+var usageString = `
+usage:
+osac list                                   Prints available products (mac, devtools, ios, server)
+osac list _product_                         Prints available releases for that product
+osac list _product_ _release_               Prints available packages for that particular (product, release)
+osac list ... --json                        Prints the same listing as JSON
+
+osac get _product_ _release_                Gets all packages for that particular (product, release)
+osac get _product_ _release_ _package_      Gets that package for the particular (product, release)
+osac get -j N _product_ _release_           Gets all packages using N concurrent workers (default 4)
+osac get _product_ _release_ _pkg_ --with-deps   Gets _pkg_ plus its transitive build dependencies
+osac get ... --extract                      Untars each package into <product>-<release>/<name>-<version>/
+osac get ... --only _glob_                   Only fetches projects in the closure matching _glob_
+
+osac snapshot _date_ list ...               Runs list against the cached view closest to _date_ (YYYY-MM-DD)
+osac snapshot _date_ get ...                Runs get against the cached view closest to _date_ (YYYY-MM-DD)
+
+Add --refresh to list/get to bypass the cache and refetch from opensource.apple.com.
+list/get also accept --rate (requests/sec, default 1), --retries (default 10),
+and --timeout (default 5m) to control how politely osac scrapes the site.
+`
+
+func printUsage() {
+	fmt.Println(usageString)
+	os.Exit(1)
+}
+
+func main() {
+	argc := len(os.Args)
+	if argc < 2 {
+		printUsage()
+	}
+
+	dir, err := cache.DefaultDir()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	c, err := cache.Open(dir)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	client := osac.NewClient(nil)
+	client.Cache = c
+
+	ctx := context.Background()
+
+	command := os.Args[1]
+	switch command {
+	case "list":
+		fs := flag.NewFlagSet("list", flag.ExitOnError)
+		refresh := fs.Bool("refresh", false, "bypass the cache and refetch from opensource.apple.com")
+		asJSON := fs.Bool("json", false, "print the listing as JSON")
+		rps, retries, timeout := addHTTPFlags(fs)
+		fs.Parse(reorderFlags(fs, os.Args[2:]))
+		client.Refresh = *refresh
+		client.Doer = newHTTPClient(*rps, *retries, *timeout)
+		args := fs.Args()
+		switch len(args) {
+		case 0:
+			doList(ctx, client, "", "", *asJSON)
+		case 1:
+			doList(ctx, client, args[0], "", *asJSON)
+		case 2:
+			doList(ctx, client, args[0], args[1], *asJSON)
+		default:
+			printUsage()
+		}
+	case "get":
+		fs := flag.NewFlagSet("get", flag.ExitOnError)
+		workers := fs.Int("j", 4, "number of concurrent download workers")
+		refresh := fs.Bool("refresh", false, "bypass the cache and refetch from opensource.apple.com")
+		withDeps := fs.Bool("with-deps", false, "also fetch the package's transitive build dependencies")
+		extract := fs.Bool("extract", false, "untar each package after downloading it")
+		only := fs.String("only", "", "only fetch packages in the closure matching this glob")
+		rps, retries, timeout := addHTTPFlags(fs)
+		fs.Parse(reorderFlags(fs, os.Args[2:]))
+		client.Refresh = *refresh
+		client.Doer = newHTTPClient(*rps, *retries, *timeout)
+		args := fs.Args()
+		switch len(args) {
+		case 2:
+			doGet(ctx, client, args[0], args[1], "", *workers, *withDeps, *extract, *only)
+		case 3:
+			doGet(ctx, client, args[0], args[1], args[2], *workers, *withDeps, *extract, *only)
+		default:
+			printUsage()
+		}
+	case "snapshot":
+		if argc < 4 {
+			printUsage()
+		}
+		t, err := time.Parse("2006-01-02", os.Args[2])
+		if err != nil {
+			log.Fatalf("snapshot: invalid date %q, want YYYY-MM-DD", os.Args[2])
+		}
+		client.SnapshotAt = &t
+		doSnapshot(ctx, client, os.Args[3], os.Args[4:])
+	default:
+		printUsage()
+	}
+}
+
+// reorderFlags moves every flag (and its value, if it takes one) in args
+// to the front and every positional argument to the back, so fs.Parse can
+// be used even when flags are documented as trailing the positional
+// arguments (e.g. "osac get mac 10.15 xnu --with-deps"). The stdlib flag
+// package otherwise stops parsing at the first non-flag argument and
+// leaves everything after it, flags included, in fs.Args().
+func reorderFlags(fs *flag.FlagSet, args []string) []string {
+	var flagArgs, positional []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if len(a) == 0 || a[0] != '-' || a == "-" {
+			positional = append(positional, a)
+			continue
+		}
+		flagArgs = append(flagArgs, a)
+		name := strings.TrimLeft(a, "-")
+		if strings.ContainsRune(name, '=') {
+			continue // value already attached as -name=value
+		}
+		if fl := fs.Lookup(name); fl != nil {
+			if bv, ok := fl.Value.(interface{ IsBoolFlag() bool }); !ok || !bv.IsBoolFlag() {
+				if i+1 < len(args) {
+					i++
+					flagArgs = append(flagArgs, args[i])
+				}
+			}
+		}
+	}
+	return append(flagArgs, positional...)
+}
+
+// addHTTPFlags registers the rate/retry/timeout flags shared by list and
+// get.
+func addHTTPFlags(fs *flag.FlagSet) (rps *float64, retries *int, timeout *time.Duration) {
+	rps = fs.Float64("rate", transport.DefaultRate, "maximum requests per second to opensource.apple.com")
+	retries = fs.Int("retries", transport.DefaultRetries, "number of attempts per request before giving up")
+	timeout = fs.Duration("timeout", 5*time.Minute, "overall timeout for a single HTTP request")
+	return rps, retries, timeout
+}
+
+// newHTTPClient builds the shared, rate-limited, retrying client used for
+// every request osac makes.
+func newHTTPClient(rps float64, retries int, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: transport.New(rps, retries),
+		Timeout:   timeout,
+	}
+}
+
+// product: optional
+// release: optional
+func doList(ctx context.Context, client *osac.Client, product, release string, asJSON bool) {
+	if product == "" {
+		printJSONOr(asJSON, client.ListProducts(), func() {
+			fmt.Println("Available products:")
+			for _, p := range client.ListProducts() {
+				fmt.Println(p.Key)
+			}
+		})
+		return
+	}
+	if release == "" {
+		releases, err := client.ListReleases(ctx, product)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		printJSONOr(asJSON, releases, func() {
+			for _, r := range releases {
+				fmt.Println(r.Name)
+			}
+		})
+		return
+	}
+	packs, err := client.ListPackages(ctx, product, release)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	printJSONOr(asJSON, packs, func() {
+		for _, p := range packs {
+			updatedStr := ""
+			if p.Updated {
+				updatedStr = "*"
+			}
+			fmt.Printf("%s (%s)%s\n", p.Name, p.Version, updatedStr)
+		}
+	})
+}
+
+func printJSONOr(asJSON bool, v interface{}, human func()) {
+	if !asJSON {
+		human()
+		return
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// product: required
+// release: required
+// targetPackage: optional; required if withDeps is set
+func doGet(ctx context.Context, client *osac.Client, product, release, targetPackage string, workers int, withDeps, extract bool, only string) {
+	packs, err := client.ListPackages(ctx, product, release)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	closure, err := buildClosure(ctx, client, packs, targetPackage, withDeps)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if only != "" {
+		closure, err = filterByGlob(closure, only)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	downloadPackages(client, product, release, closure, workers, extract)
+}
+
+// buildClosure resolves which packages to download. With withDeps unset it
+// is either every package in packs (root == "") or just root. With
+// withDeps set, root is required and the result is its transitive build
+// dependency closure.
+func buildClosure(ctx context.Context, client *osac.Client, packs []osac.Package, root string, withDeps bool) ([]osac.Package, error) {
+	byName := make(map[string]osac.Package, len(packs))
+	for _, p := range packs {
+		byName[p.Name] = p
+	}
+
+	if !withDeps {
+		if root == "" {
+			return packs, nil
+		}
+		p, ok := byName[root]
+		if !ok {
+			return nil, fmt.Errorf("get: %s is not in this release's package listing", root)
+		}
+		return []osac.Package{p}, nil
+	}
+
+	if root == "" {
+		return nil, fmt.Errorf("get: --with-deps requires a package name")
+	}
+	projects := make(map[string]deps.Project, len(byName))
+	for name, p := range byName {
+		projects[name] = deps.Project{Name: p.Name, URL: p.URL}
+	}
+	names, err := deps.Resolve(root, projects, func(url string) (io.Reader, error) {
+		return fetchTarballForDeps(ctx, client, url)
+	})
+	if err != nil {
+		return nil, err
+	}
+	closure := make([]osac.Package, 0, len(names))
+	for _, n := range names {
+		closure = append(closure, byName[n])
+	}
+	return closure, nil
+}
+
+// fetchTarballForDeps returns url's tarball for dependency inspection,
+// preferring client.Cache the same way downloadPackages' CacheLookup does
+// so that `snapshot ... get --with-deps` never reaches the network: the
+// dependency graph of a frozen release must resolve from the same cached
+// view the rest of snapshot mode uses. A cache miss is a hard error under
+// client.SnapshotAt; otherwise it falls back to fetchTarball.
+func fetchTarballForDeps(ctx context.Context, client *osac.Client, url string) (io.Reader, error) {
+	if client.Cache != nil && !client.Refresh {
+		if entry, err := client.Cache.LatestTarball(url); err == nil && entry != nil {
+			if f, err := os.Open(client.Cache.TarballPath(*entry)); err == nil {
+				return f, nil
+			}
+		}
+	}
+	if client.SnapshotAt != nil {
+		return nil, fmt.Errorf("snapshot: %s not in tarball cache and running offline", url)
+	}
+	return fetchTarball(ctx, client.Doer, url)
+}
+
+// fetchTarball fetches url and returns its body for dependency inspection.
+// It is deliberately uncached: the resulting tarball is discarded once
+// deps.ParseDependencies has read it, and the real download that follows
+// goes through the cache-aware downloader instead.
+func fetchTarball(ctx context.Context, doer osac.HTTPDoer, url string) (io.Reader, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("http: couldn't build request for %s: %w", url, err)
+	}
+	res, err := doer.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("http: couldn't get url: %s: %w", url, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("http: got non 200 status code: %s %d", url, res.StatusCode)
+	}
+	return res.Body, nil
+}
+
+func filterByGlob(packs []osac.Package, pattern string) ([]osac.Package, error) {
+	filtered := make([]osac.Package, 0, len(packs))
+	for _, p := range packs {
+		matched, err := filepath.Match(pattern, p.Name)
+		if err != nil {
+			return nil, fmt.Errorf("get: bad --only pattern %q: %w", pattern, err)
+		}
+		if matched {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered, nil
+}
+
+func downloadPackages(client *osac.Client, product, release string, packs []osac.Package, workers int, extract bool) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		log.Fatalln("download: couldn't get cwd")
+	}
+	path := filepath.Join(cwd, product+"-"+release)
+
+	dlPacks := make([]downloader.Package, len(packs))
+	for i, p := range packs {
+		dlPacks[i] = downloader.Package{Name: p.Name, Version: p.Version, URL: p.URL}
+	}
+
+	d := downloader.New(path, workers)
+	if hc, ok := client.Doer.(*http.Client); ok {
+		d.Client = hc
+	}
+	d.Offline = client.SnapshotAt != nil
+	d.Extract = extract
+	d.CacheLookup = func(url string) (string, bool) {
+		if client.Refresh {
+			return "", false
+		}
+		entry, err := client.Cache.LatestTarball(url)
+		if err != nil || entry == nil {
+			return "", false
+		}
+		return client.Cache.TarballPath(*entry), true
+	}
+	d.OnComplete = func(p downloader.Package, path string) {
+		if _, err := client.Cache.StoreTarballFile(p.URL, path, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "cache: couldn't store tarball for %s: %v\n", p.Name, err)
+		}
+	}
+	results := d.DownloadAll(dlPacks)
+	downloader.Summary(os.Stdout, results)
+}
+
+// doSnapshot dispatches a "list" or "get" subcommand against the cached
+// view closest to client.SnapshotAt, with no network fallback.
+func doSnapshot(ctx context.Context, client *osac.Client, sub string, rest []string) {
+	switch sub {
+	case "list":
+		fs := flag.NewFlagSet("snapshot list", flag.ExitOnError)
+		asJSON := fs.Bool("json", false, "print the listing as JSON")
+		fs.Parse(reorderFlags(fs, rest))
+		args := fs.Args()
+		switch len(args) {
+		case 0:
+			doList(ctx, client, "", "", *asJSON)
+		case 1:
+			doList(ctx, client, args[0], "", *asJSON)
+		case 2:
+			doList(ctx, client, args[0], args[1], *asJSON)
+		default:
+			printUsage()
+		}
+	case "get":
+		fs := flag.NewFlagSet("snapshot get", flag.ExitOnError)
+		workers := fs.Int("j", 4, "number of concurrent download workers")
+		withDeps := fs.Bool("with-deps", false, "also fetch the package's transitive build dependencies")
+		extract := fs.Bool("extract", false, "untar each package after downloading it")
+		only := fs.String("only", "", "only fetch packages in the closure matching this glob")
+		fs.Parse(reorderFlags(fs, rest))
+		args := fs.Args()
+		switch len(args) {
+		case 2:
+			doGet(ctx, client, args[0], args[1], "", *workers, *withDeps, *extract, *only)
+		case 3:
+			doGet(ctx, client, args[0], args[1], args[2], *workers, *withDeps, *extract, *only)
+		default:
+			printUsage()
+		}
+	default:
+		printUsage()
+	}
+}
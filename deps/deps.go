@@ -0,0 +1,182 @@
+// Package deps discovers the companion projects a package's tarball
+// declares it needs, and resolves them into the transitive closure
+// required to build it.
+//
+// Apple's opensource projects commonly pin their required companion
+// projects and versions in either their top-level Makefile (a
+// "Dependencies" variable) or a *.plist file (a "Dependencies" array of
+// strings). Both are parsed on a best-effort basis: a tarball with neither
+// is simply treated as having no declared dependencies.
+package deps
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Dependency is a companion project referenced by another project's
+// tarball, optionally pinned to a specific version.
+type Dependency struct {
+	Name    string
+	Version string
+}
+
+// ParseDependencies scans a gzip-compressed tarball for a Makefile or
+// *.plist file declaring companion projects and returns what it finds.
+func ParseDependencies(r io.Reader) ([]Dependency, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("deps: not a valid gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	var found []Dependency
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("deps: not a valid tar archive: %w", err)
+		}
+		base := hdr.FileInfo().Name()
+		isMakefile := strings.EqualFold(base, "Makefile")
+		isPlist := strings.HasSuffix(strings.ToLower(base), ".plist")
+		if !isMakefile && !isPlist {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("deps: couldn't read %s: %w", hdr.Name, err)
+		}
+		if isMakefile {
+			found = append(found, parseMakefileDeps(data)...)
+		} else {
+			found = append(found, parsePlistDeps(data)...)
+		}
+	}
+	return dedupe(found), nil
+}
+
+var makefileDepsRe = regexp.MustCompile(`(?m)^\s*Dependencies\s*[:?]?=\s*(.+)$`)
+
+func parseMakefileDeps(data []byte) []Dependency {
+	m := makefileDepsRe.FindSubmatch(data)
+	if m == nil {
+		return nil
+	}
+	var deps []Dependency
+	for _, tok := range strings.Fields(string(m[1])) {
+		deps = append(deps, splitPinned(tok))
+	}
+	return deps
+}
+
+var (
+	plistDepsRe   = regexp.MustCompile(`(?s)<key>Dependencies</key>\s*<array>(.*?)</array>`)
+	plistStringRe = regexp.MustCompile(`<string>([^<]+)</string>`)
+)
+
+func parsePlistDeps(data []byte) []Dependency {
+	block := plistDepsRe.FindSubmatch(data)
+	if block == nil {
+		return nil
+	}
+	var deps []Dependency
+	for _, m := range plistStringRe.FindAllSubmatch(block[1], -1) {
+		deps = append(deps, splitPinned(string(m[1])))
+	}
+	return deps
+}
+
+// splitPinned splits a "name-version" token into its parts. A token with
+// no version suffix is returned with an empty Version.
+func splitPinned(tok string) Dependency {
+	if i := strings.LastIndex(tok, "-"); i > 0 {
+		return Dependency{Name: tok[:i], Version: tok[i+1:]}
+	}
+	return Dependency{Name: tok}
+}
+
+func dedupe(deps []Dependency) []Dependency {
+	seen := make(map[string]bool, len(deps))
+	out := make([]Dependency, 0, len(deps))
+	for _, d := range deps {
+		if seen[d.Name] {
+			continue
+		}
+		seen[d.Name] = true
+		out = append(out, d)
+	}
+	return out
+}
+
+// Project is the subset of package metadata needed to resolve a
+// dependency closure.
+type Project struct {
+	Name string
+	URL  string
+}
+
+// Resolve walks the dependency closure of root, fetching each visited
+// project's tarball via fetch to discover its declared dependencies, and
+// matching them by name against the release's own package listing in
+// projects. Only one version of each project ever ships in a given
+// release, so a pinned version in a Makefile/plist is informational only;
+// the release's own version always wins.
+//
+// The returned names are in a valid build order: a project never appears
+// before the dependencies it declared, except where a dependency cycle
+// makes that impossible; a cycle is cut where it closes, and every
+// project in it still appears once.
+func Resolve(root string, projects map[string]Project, fetch func(url string) (io.Reader, error)) ([]string, error) {
+	visited := make(map[string]bool)
+	visiting := make(map[string]bool)
+	var order []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		if visited[name] {
+			return nil
+		}
+		if visiting[name] {
+			return nil // cycle; the project is already on the stack
+		}
+		p, ok := projects[name]
+		if !ok {
+			return fmt.Errorf("deps: %s is not in this release's package listing", name)
+		}
+		visiting[name] = true
+
+		r, err := fetch(p.URL)
+		if err != nil {
+			return fmt.Errorf("deps: couldn't fetch %s to inspect its dependencies: %w", name, err)
+		}
+		declared, parseErr := ParseDependencies(r)
+		if closer, ok := r.(io.Closer); ok {
+			closer.Close()
+		}
+		if parseErr == nil {
+			for _, d := range declared {
+				if err := visit(d.Name); err != nil {
+					return err
+				}
+			}
+		}
+
+		visiting[name] = false
+		visited[name] = true
+		order = append(order, name)
+		return nil
+	}
+
+	if err := visit(root); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
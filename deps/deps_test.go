@@ -0,0 +1,206 @@
+package deps
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sort"
+	"testing"
+	"time"
+)
+
+func timeoutC(t *testing.T) <-chan time.Time {
+	t.Helper()
+	return time.After(2 * time.Second)
+}
+
+// makeTarGz builds an in-memory gzip-compressed tarball containing the
+// given files (path -> contents).
+func makeTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, contents := range files {
+		hdr := &tar.Header{Name: name, Size: int64(len(contents)), Mode: 0644}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("WriteHeader(%s): %v", name, err)
+		}
+		if _, err := tw.Write([]byte(contents)); err != nil {
+			t.Fatalf("Write(%s): %v", name, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar Close: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseDependenciesMakefile(t *testing.T) {
+	archive := makeTarGz(t, map[string]string{
+		"xnu-1234/Makefile": "all:\n\techo hi\n\nDependencies = libkern-10 IOKitUser-20\n",
+	})
+	deps, err := ParseDependencies(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("ParseDependencies: %v", err)
+	}
+	want := map[string]string{"libkern": "10", "IOKitUser": "20"}
+	if len(deps) != len(want) {
+		t.Fatalf("got %d deps, want %d: %+v", len(deps), len(want), deps)
+	}
+	for _, d := range deps {
+		if v, ok := want[d.Name]; !ok || v != d.Version {
+			t.Errorf("unexpected dependency %+v", d)
+		}
+	}
+}
+
+func TestParseDependenciesPlist(t *testing.T) {
+	plist := `<?xml version="1.0"?>
+<plist>
+<dict>
+	<key>Dependencies</key>
+	<array>
+		<string>libkern-10</string>
+		<string>IOKitUser-20</string>
+	</array>
+</dict>
+</plist>`
+	archive := makeTarGz(t, map[string]string{"xnu-1234/xnu.plist": plist})
+	deps, err := ParseDependencies(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("ParseDependencies: %v", err)
+	}
+	if len(deps) != 2 {
+		t.Fatalf("got %d deps, want 2: %+v", len(deps), deps)
+	}
+}
+
+func TestParseDependenciesNone(t *testing.T) {
+	archive := makeTarGz(t, map[string]string{"xnu-1234/README": "nothing to see here"})
+	deps, err := ParseDependencies(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("ParseDependencies: %v", err)
+	}
+	if len(deps) != 0 {
+		t.Fatalf("got %d deps, want 0: %+v", len(deps), deps)
+	}
+}
+
+// fakeProjects builds a Project map and a fetch func from a dependency
+// graph (name -> declared dependency names), so Resolve can be tested
+// without any real tarballs or network.
+func fakeProjects(graph map[string][]string) (map[string]Project, func(url string) (io.Reader, error)) {
+	projects := make(map[string]Project, len(graph))
+	for name := range graph {
+		projects[name] = Project{Name: name, URL: "fake://" + name}
+	}
+	fetch := func(url string) (io.Reader, error) {
+		name := url[len("fake://"):]
+		var sb bytes.Buffer
+		sb.WriteString("Dependencies = ")
+		for _, d := range graph[name] {
+			sb.WriteString(d + " ")
+		}
+		sb.WriteString("\n")
+		var files = map[string]string{name + "/Makefile": sb.String()}
+		return bytes.NewReader(makeTarGzFromMap(files)), nil
+	}
+	return projects, fetch
+}
+
+func makeTarGzFromMap(files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, contents := range files {
+		hdr := &tar.Header{Name: name, Size: int64(len(contents)), Mode: 0644}
+		tw.WriteHeader(hdr)
+		tw.Write([]byte(contents))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestResolveClosure(t *testing.T) {
+	projects, fetch := fakeProjects(map[string][]string{
+		"xnu":         {"libkern", "IOKitUser"},
+		"libkern":     {"xnu_headers"},
+		"IOKitUser":   {"xnu_headers"},
+		"xnu_headers": nil,
+	})
+	names, err := Resolve("xnu", projects, fetch)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	sort.Strings(names)
+	want := []string{"IOKitUser", "libkern", "xnu", "xnu_headers"}
+	if len(names) != len(want) {
+		t.Fatalf("got %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("got %v, want %v", names, want)
+		}
+	}
+}
+
+func TestResolveBuildOrder(t *testing.T) {
+	projects, fetch := fakeProjects(map[string][]string{
+		"a": {"b"},
+		"b": {"c"},
+		"c": nil,
+	})
+	names, err := Resolve("a", projects, fetch)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	pos := make(map[string]int, len(names))
+	for i, n := range names {
+		pos[n] = i
+	}
+	if pos["c"] > pos["b"] || pos["b"] > pos["a"] {
+		t.Fatalf("dependency appeared after its dependent: %v", names)
+	}
+}
+
+func TestResolveCycle(t *testing.T) {
+	// a -> b -> a: Resolve must terminate instead of recursing forever,
+	// and both projects must still end up in the closure.
+	projects, fetch := fakeProjects(map[string][]string{
+		"a": {"b"},
+		"b": {"a"},
+	})
+	done := make(chan struct{})
+	var names []string
+	var err error
+	go func() {
+		names, err = Resolve("a", projects, fetch)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-timeoutC(t):
+		t.Fatal("Resolve did not terminate on a dependency cycle")
+	}
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if len(names) != 2 {
+		t.Fatalf("got %v, want both a and b", names)
+	}
+}
+
+func TestResolveMissingDependency(t *testing.T) {
+	projects, fetch := fakeProjects(map[string][]string{
+		"a": {"does-not-exist"},
+	})
+	if _, err := Resolve("a", projects, fetch); err == nil {
+		t.Fatal("expected an error for a dependency missing from the release listing")
+	}
+}
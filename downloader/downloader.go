@@ -0,0 +1,383 @@
+// Package downloader fetches osac packages concurrently, resuming partial
+// downloads and verifying each tarball before it is handed back to the
+// caller.
+package downloader
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Package is the subset of package metadata the downloader needs in order
+// to fetch and verify a file. It is intentionally smaller than the osac
+// Package type so this package stays decoupled from the scraper.
+type Package struct {
+	Name    string
+	Version string
+	URL     string
+}
+
+// Result is the outcome of downloading a single Package.
+type Result struct {
+	Package  Package
+	Filename string
+	Err      error
+}
+
+// Downloader fetches a set of Packages into Dir using a bounded pool of
+// Workers goroutines.
+type Downloader struct {
+	Dir     string
+	Workers int
+	Client  *http.Client
+
+	// CacheLookup, if set, is consulted before any network request. If it
+	// returns ok, the file at the returned path is copied into Dir instead
+	// of fetching p.URL.
+	CacheLookup func(url string) (path string, ok bool)
+
+	// OnComplete, if set, is called after a package has been downloaded
+	// and verified (but not when it was satisfied by CacheLookup).
+	OnComplete func(p Package, path string)
+
+	// Offline, when true, makes a CacheLookup miss a hard error instead of
+	// falling back to the network. Used for snapshot-style reproduction of
+	// an earlier cached state.
+	Offline bool
+
+	// Extract, when true, untars each verified package into
+	// Dir/<name>-<version>/ after it is downloaded (or satisfied by
+	// CacheLookup).
+	Extract bool
+}
+
+// New returns a Downloader that writes into dir using the given number of
+// concurrent workers. A workers value less than 1 is treated as 1.
+func New(dir string, workers int) *Downloader {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Downloader{
+		Dir:     dir,
+		Workers: workers,
+		Client:  http.DefaultClient,
+	}
+}
+
+// DownloadAll fetches every package in packs into d.Dir, bounded by
+// d.Workers concurrent downloads. It never aborts the run early: a failure
+// on one package is recorded in its Result and the rest continue. The
+// returned slice is in the same order as packs.
+func (d *Downloader) DownloadAll(packs []Package) []Result {
+	if err := os.MkdirAll(d.Dir, os.ModePerm); err != nil {
+		results := make([]Result, len(packs))
+		for i, p := range packs {
+			results[i] = Result{Package: p, Err: fmt.Errorf("downloader: couldn't create dir %s: %w", d.Dir, err)}
+		}
+		return results
+	}
+
+	results := make([]Result, len(packs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	rows := d.Workers
+	if rows < 1 {
+		rows = 1
+	}
+	board := newProgressBoard(rows)
+
+	for w := 0; w < d.Workers; w++ {
+		wg.Add(1)
+		go func(row int) {
+			defer wg.Done()
+			for i := range jobs {
+				p := packs[i]
+				filename, err := d.downloadOne(p, board, row)
+				results[i] = Result{Package: p, Filename: filename, Err: err}
+			}
+		}(w)
+	}
+
+	for i := range packs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// downloadOne downloads a single package, resuming from a .part file if one
+// already exists, and verifies the .part file as a valid .tar.gz before it
+// is renamed to its final filename. Progress is drawn on board's row-th
+// line, so concurrent downloadOne calls never interleave their output.
+func (d *Downloader) downloadOne(p Package, board *progressBoard, row int) (string, error) {
+	filename := filepath.Join(d.Dir, filepath.Base(p.URL))
+
+	if d.CacheLookup != nil {
+		if cached, ok := d.CacheLookup(p.URL); ok {
+			if err := copyFile(cached, filename); err != nil {
+				return "", fmt.Errorf("downloader: couldn't copy cached file for %s: %w", p.URL, err)
+			}
+			return filename, d.maybeExtract(p, filename)
+		}
+		if d.Offline {
+			return "", fmt.Errorf("downloader: %s not in cache and running offline", p.URL)
+		}
+	}
+
+	partFilename := filename + ".part"
+
+	var offset int64
+	if fi, err := os.Stat(partFilename); err == nil {
+		offset = fi.Size()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, p.URL, nil)
+	if err != nil {
+		return "", fmt.Errorf("downloader: couldn't build request for %s: %w", p.URL, err)
+	}
+	resumed := false
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("downloader: couldn't download %s: %w", p.URL, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		offset = 0
+	case http.StatusPartialContent:
+		resumed = true
+	default:
+		return "", fmt.Errorf("downloader: got non-2xx status for %s: %d", p.URL, resp.StatusCode)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	if resumed {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+	out, err := os.OpenFile(partFilename, flags, 0644)
+	if err != nil {
+		return "", fmt.Errorf("downloader: couldn't open %s: %w", partFilename, err)
+	}
+
+	total := resp.ContentLength
+	if resumed && total >= 0 {
+		total += offset // resp.ContentLength is just the remaining range, not the full file
+	}
+	progress := &progressWriter{name: p.Name, total: total, written: offset, board: board, row: row}
+	_, err = io.Copy(out, io.TeeReader(resp.Body, progress))
+	out.Close()
+	if err != nil {
+		return "", fmt.Errorf("downloader: couldn't write %s: %w", partFilename, err)
+	}
+
+	if err := verifyTarGz(partFilename); err != nil {
+		return "", fmt.Errorf("downloader: %s failed verification: %w", filename, err)
+	}
+
+	if err := os.Rename(partFilename, filename); err != nil {
+		return "", fmt.Errorf("downloader: couldn't finalize %s: %w", filename, err)
+	}
+
+	if d.OnComplete != nil {
+		d.OnComplete(p, filename)
+	}
+
+	return filename, d.maybeExtract(p, filename)
+}
+
+// maybeExtract untars filename into Dir/<name>-<version>/ when d.Extract is
+// set.
+func (d *Downloader) maybeExtract(p Package, filename string) error {
+	if !d.Extract {
+		return nil
+	}
+	destDir := filepath.Join(d.Dir, p.Name+"-"+p.Version)
+	if err := extractTarGz(filename, destDir); err != nil {
+		return fmt.Errorf("downloader: couldn't extract %s: %w", filename, err)
+	}
+	return nil
+}
+
+// extractTarGz untars the gzip-compressed archive at archivePath into
+// destDir, creating it if necessary.
+func extractTarGz(archivePath, destDir string) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("not a valid gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("not a valid tar archive: %w", err)
+		}
+
+		target := filepath.Join(destDir, hdr.Name)
+		if !strings.HasPrefix(target, filepath.Clean(destDir)+string(os.PathSeparator)) {
+			return fmt.Errorf("tar entry escapes destination: %s", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(hdr.Mode))
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			out.Close()
+		}
+	}
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// verifyTarGz opens name as a gzip-compressed tar archive and reads it
+// through to the end, which is enough to catch a truncated or corrupt
+// download without fully extracting it.
+func verifyTarGz(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("not a valid gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		_, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("not a valid tar archive: %w", err)
+		}
+	}
+}
+
+// progressBoard multiplexes progress output from concurrent downloads onto
+// a fixed block of rows terminal lines reserved below the cursor position
+// at the time it's created, one row per worker, so concurrent writers never
+// interleave their output on the same line.
+type progressBoard struct {
+	mu   sync.Mutex
+	rows int
+}
+
+// newProgressBoard reserves rows blank lines and returns a board that can
+// redraw any of them independently.
+func newProgressBoard(rows int) *progressBoard {
+	if rows < 1 {
+		rows = 1
+	}
+	for i := 0; i < rows; i++ {
+		fmt.Println()
+	}
+	return &progressBoard{rows: rows}
+}
+
+// update redraws row (0-indexed from the top of the reserved block) with s,
+// leaving the cursor back where it started.
+func (b *progressBoard) update(row int, s string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	up := b.rows - row
+	fmt.Printf("\033[%dA\r\033[K%s\033[%dB\r", up, s, up)
+}
+
+// progressWriter reports the download progress of a single package onto its
+// own line of a shared progressBoard.
+type progressWriter struct {
+	name    string
+	total   int64
+	written int64
+
+	board *progressBoard
+	row   int
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	pw.written += int64(len(p))
+	var line string
+	if pw.total > 0 {
+		line = fmt.Sprintf("%-30s %6.2f%%", pw.name, float64(pw.written)/float64(pw.total)*100)
+	} else {
+		line = fmt.Sprintf("%-30s %d bytes", pw.name, pw.written)
+	}
+	pw.board.update(pw.row, line)
+	return len(p), nil
+}
+
+// Summary writes a one-line total followed by one line per failed package.
+func Summary(w io.Writer, results []Result) {
+	var ok, failed int
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		} else {
+			ok++
+		}
+	}
+	fmt.Fprintf(w, "downloaded %d/%d packages\n", ok, len(results))
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(w, "  failed: %s: %v\n", r.Package.Name, r.Err)
+		}
+	}
+}
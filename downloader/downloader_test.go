@@ -0,0 +1,164 @@
+package downloader
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// makeTarGz builds a small valid gzip-compressed tarball so downloadOne's
+// verification step has something real to check.
+func makeTarGz(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	contents := "xnu source goes here"
+	if err := tw.WriteHeader(&tar.Header{Name: "xnu-1234/README", Size: int64(len(contents)), Mode: 0644}); err != nil {
+		t.Fatalf("WriteHeader: %v", err)
+	}
+	if _, err := tw.Write([]byte(contents)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar Close: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDownloadAllFreshDownload(t *testing.T) {
+	archive := makeTarGz(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	d := New(dir, 1)
+	var completed []string
+	d.OnComplete = func(p Package, path string) { completed = append(completed, path) }
+
+	pack := Package{Name: "xnu", Version: "1234", URL: srv.URL + "/xnu-1234.tar.gz"}
+	results := d.DownloadAll([]Package{pack})
+	if results[0].Err != nil {
+		t.Fatalf("downloadOne: %v", results[0].Err)
+	}
+
+	got, err := os.ReadFile(results[0].Filename)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(got, archive) {
+		t.Fatalf("downloaded file doesn't match the served archive")
+	}
+	if len(completed) != 1 || completed[0] != results[0].Filename {
+		t.Fatalf("OnComplete: got %v, want [%s]", completed, results[0].Filename)
+	}
+}
+
+func TestDownloadAllResumesPartialDownload(t *testing.T) {
+	archive := makeTarGz(t)
+	const splitAt = 20
+	if splitAt >= len(archive) {
+		t.Fatalf("test archive too small to split at %d bytes", splitAt)
+	}
+
+	var gotRange string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRange = r.Header.Get("Range")
+		if gotRange == "" {
+			t.Fatalf("server got a request without a Range header; resume didn't send one")
+		}
+		w.Header().Set("Content-Range", "bytes "+strconv.Itoa(splitAt)+"-"+strconv.Itoa(len(archive)-1)+"/"+strconv.Itoa(len(archive)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(archive[splitAt:])
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	pack := Package{Name: "xnu", Version: "1234", URL: srv.URL + "/xnu-1234.tar.gz"}
+	partPath := filepath.Join(dir, "xnu-1234.tar.gz.part")
+	if err := os.WriteFile(partPath, archive[:splitAt], 0644); err != nil {
+		t.Fatalf("seeding .part file: %v", err)
+	}
+
+	d := New(dir, 1)
+	results := d.DownloadAll([]Package{pack})
+	if results[0].Err != nil {
+		t.Fatalf("downloadOne: %v", results[0].Err)
+	}
+
+	got, err := os.ReadFile(results[0].Filename)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(got, archive) {
+		t.Fatalf("resumed file doesn't match the original archive (got %d bytes, want %d)", len(got), len(archive))
+	}
+}
+
+func TestDownloadAllRejectsCorruptDownload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not a valid tar.gz"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	d := New(dir, 1)
+	pack := Package{Name: "xnu", Version: "1234", URL: srv.URL + "/xnu-1234.tar.gz"}
+	results := d.DownloadAll([]Package{pack})
+	if results[0].Err == nil {
+		t.Fatal("expected a verification error for a corrupt download")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "xnu-1234.tar.gz")); err == nil {
+		t.Fatal("a corrupt download should not be renamed into its final filename")
+	}
+}
+
+func TestDownloadAllUsesCacheLookup(t *testing.T) {
+	archive := makeTarGz(t)
+	cacheDir := t.TempDir()
+	cachedPath := filepath.Join(cacheDir, "cached.tar.gz")
+	if err := os.WriteFile(cachedPath, archive, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	dir := t.TempDir()
+	d := New(dir, 1)
+	d.CacheLookup = func(url string) (string, bool) { return cachedPath, true }
+
+	pack := Package{Name: "xnu", Version: "1234", URL: "https://example.invalid/xnu-1234.tar.gz"}
+	results := d.DownloadAll([]Package{pack})
+	if results[0].Err != nil {
+		t.Fatalf("downloadOne: %v", results[0].Err)
+	}
+	got, err := os.ReadFile(results[0].Filename)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(got, archive) {
+		t.Fatal("file copied from CacheLookup doesn't match the cached archive")
+	}
+}
+
+func TestDownloadAllOfflineCacheMiss(t *testing.T) {
+	dir := t.TempDir()
+	d := New(dir, 1)
+	d.Offline = true
+	d.CacheLookup = func(url string) (string, bool) { return "", false }
+
+	pack := Package{Name: "xnu", Version: "1234", URL: "https://example.invalid/xnu-1234.tar.gz"}
+	results := d.DownloadAll([]Package{pack})
+	if results[0].Err == nil {
+		t.Fatal("expected an error for a cache miss while offline")
+	}
+}
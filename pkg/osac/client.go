@@ -0,0 +1,299 @@
+// Package osac is a client library for scraping product, release, and
+// package listings from opensource.apple.com and downloading the
+// tarballs they link to. cmd/osac is a thin CLI built on top of it.
+package osac
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/alexmullins/osac/cache"
+)
+
+// HTTPDoer is the subset of *http.Client a Client needs to make requests.
+// Callers can supply their own implementation to inject retries, rate
+// limiting, or logging.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client scrapes opensource.apple.com. Its zero value is not usable; use
+// NewClient.
+type Client struct {
+	// Doer makes the HTTP requests. Defaults to http.DefaultClient.
+	Doer HTTPDoer
+	// BaseURL is the root of the site to scrape. Defaults to
+	// https://opensource.apple.com.
+	BaseURL string
+	// Cache, if set, is consulted and updated for every page fetch.
+	Cache *cache.Cache
+	// Refresh bypasses cache revalidation and always refetches from
+	// BaseURL.
+	Refresh bool
+	// SnapshotAt, if set, restricts every page fetch to the cached entry
+	// closest to (but not after) this time, or to the earliest cached entry
+	// if every one is after it, with no network fallback.
+	// It requires Cache to be set.
+	SnapshotAt *time.Time
+}
+
+// NewClient returns a Client that makes requests with doer. A nil doer
+// defaults to http.DefaultClient.
+func NewClient(doer HTTPDoer) *Client {
+	if doer == nil {
+		doer = http.DefaultClient
+	}
+	return &Client{
+		Doer:    doer,
+		BaseURL: "https://opensource.apple.com",
+	}
+}
+
+// Product is one of the software categories opensource.apple.com groups
+// releases under.
+type Product struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+var products = []Product{
+	{Key: "mac", Name: "macOS"},
+	{Key: "devtools", Name: "Developer Tools"},
+	{Key: "ios", Name: "iOS"},
+	{Key: "server", Name: "OS X Server"},
+}
+
+// ListProducts returns the available products. It makes no network
+// request.
+func (c *Client) ListProducts() []Product {
+	return append([]Product(nil), products...)
+}
+
+func productDisplayName(key string) (string, bool) {
+	for _, p := range products {
+		if p.Key == key {
+			return p.Name, true
+		}
+	}
+	return "", false
+}
+
+// Release is one version of a product, e.g. product "mac" release
+// "10.15".
+type Release struct {
+	Product string `json:"product"`
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+}
+
+// ListReleases returns the releases available for product.
+func (c *Client) ListReleases(ctx context.Context, product string) ([]Release, error) {
+	displayName, ok := productDisplayName(product)
+	if !ok {
+		return nil, fmt.Errorf("osac: unknown product %q", product)
+	}
+
+	doc, err := c.getDocument(ctx, c.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []Release
+	var findErr error
+	doc.Find(".product").EachWithBreak(func(i int, s *goquery.Selection) bool {
+		if s.Find(".product-name").Text() != displayName {
+			return true
+		}
+		s.Find("ul > li > a").Each(func(ii int, ss *goquery.Selection) {
+			name := ss.Text()
+			href, ok := ss.Attr("href")
+			if !ok {
+				findErr = fmt.Errorf("osac: couldn't find href for release %q", name)
+				return
+			}
+			releases = append(releases, Release{Product: product, Name: name, URL: c.BaseURL + href})
+		})
+		return false
+	})
+	if findErr != nil {
+		return nil, findErr
+	}
+	return releases, nil
+}
+
+// Package is a single downloadable project within a (product, release).
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Updated bool   `json:"updated"`
+	URL     string `json:"url"`
+}
+
+// ListPackages returns the packages available for a (product, release).
+func (c *Client) ListPackages(ctx context.Context, product, release string) ([]Package, error) {
+	releases, err := c.ListReleases(ctx, product)
+	if err != nil {
+		return nil, err
+	}
+	var target *Release
+	for i := range releases {
+		if releases[i].Name == release {
+			target = &releases[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("osac: couldn't find release %q for product %q", release, product)
+	}
+
+	doc, err := c.getDocument(ctx, target.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	var packs []Package
+	var parseErr error
+	doc.Find(".project-row").Each(func(i int, s *goquery.Selection) {
+		if parseErr != nil {
+			return
+		}
+		nameNode := s.Find(".project-name")
+		downloadNode := s.Find(".project-downloads")
+		aNode := nameNode.Find("a")
+		if aNode.Length() == 0 {
+			return
+		}
+		updated := nameNode.HasClass("newproject")
+		name := strings.TrimSpace(aNode.Text())
+		name, version := splitProjectName(name)
+		href, ok := downloadNode.Find("a").Attr("href")
+		if !ok {
+			parseErr = fmt.Errorf("osac: couldn't find download href for %q", name)
+			return
+		}
+		packs = append(packs, Package{Name: name, Version: version, Updated: updated, URL: c.BaseURL + href})
+	})
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return packs, nil
+}
+
+func splitProjectName(s string) (string, string) {
+	ss := strings.Split(s, "-")
+	if len(ss) == 2 {
+		return ss[0], ss[1]
+	}
+	return ss[0], "problem"
+}
+
+// Download fetches pkg's tarball and writes it to w. Callers that need
+// resumable, verified, concurrent downloads of many packages should use
+// the downloader package instead; Download fetches a single file in one
+// request, with no resume or verification.
+func (c *Client) Download(ctx context.Context, pkg Package, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pkg.URL, nil)
+	if err != nil {
+		return fmt.Errorf("osac: couldn't build request for %s: %w", pkg.URL, err)
+	}
+	res, err := c.Doer.Do(req)
+	if err != nil {
+		return fmt.Errorf("osac: couldn't download %s: %w", pkg.URL, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("osac: got non 200 status code: %s %d", pkg.URL, res.StatusCode)
+	}
+	if _, err := io.Copy(w, res.Body); err != nil {
+		return fmt.Errorf("osac: couldn't copy body for %s: %w", pkg.URL, err)
+	}
+	return nil
+}
+
+// getDocument fetches and parses url, going through the cache when one is
+// configured.
+func (c *Client) getDocument(ctx context.Context, url string) (*goquery.Document, error) {
+	body, err := c.fetchBody(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("osac: couldn't parse document from %s: %w", url, err)
+	}
+	return doc, nil
+}
+
+// fetchBody returns the body for url, either from c.Cache or from
+// c.BaseURL's origin, revalidating with ETag/Last-Modified when a cached
+// copy already exists.
+func (c *Client) fetchBody(ctx context.Context, url string) ([]byte, error) {
+	if c.SnapshotAt != nil {
+		if c.Cache == nil {
+			return nil, fmt.Errorf("osac: SnapshotAt requires Cache to be set")
+		}
+		entry, err := c.Cache.ClosestPage(url, *c.SnapshotAt)
+		if err != nil {
+			return nil, fmt.Errorf("osac: snapshot: %w", err)
+		}
+		return c.Cache.ReadPage(*entry)
+	}
+
+	var latest *cache.PageEntry
+	if c.Cache != nil {
+		var err error
+		latest, err = c.Cache.LatestPage(url)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("osac: couldn't build request for %s: %w", url, err)
+	}
+	if latest != nil && !c.Refresh {
+		if latest.ETag != "" {
+			req.Header.Set("If-None-Match", latest.ETag)
+		}
+		if latest.LastModified != "" {
+			req.Header.Set("If-Modified-Since", latest.LastModified)
+		}
+	}
+
+	res, err := c.Doer.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("osac: couldn't get url %s: %w", url, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotModified && latest != nil {
+		if c.Cache != nil {
+			if _, err := c.Cache.RecordRevalidation(url, time.Now()); err != nil {
+				return nil, err
+			}
+		}
+		return c.Cache.ReadPage(*latest)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("osac: got non 200 status code: %s %d", url, res.StatusCode)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("osac: couldn't read body for %s: %w", url, err)
+	}
+	if c.Cache != nil {
+		if _, err := c.Cache.StorePage(url, body, res.Header.Get("ETag"), res.Header.Get("Last-Modified"), time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
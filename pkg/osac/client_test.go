@@ -0,0 +1,126 @@
+package osac
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexmullins/osac/cache"
+)
+
+func openTestCache(t *testing.T) *cache.Cache {
+	t.Helper()
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("cache.Open: %v", err)
+	}
+	return c
+}
+
+func TestFetchBodyRevalidatesAgainstETag(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("ETag", "\"v1\"")
+		if r.Header.Get("If-None-Match") == "\"v1\"" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("page body"))
+	}))
+	defer srv.Close()
+
+	c := NewClient(http.DefaultClient)
+	c.Cache = openTestCache(t)
+
+	body, err := c.fetchBody(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("fetchBody (first): %v", err)
+	}
+	if string(body) != "page body" {
+		t.Fatalf("got %q, want %q", body, "page body")
+	}
+
+	body, err = c.fetchBody(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("fetchBody (second): %v", err)
+	}
+	if string(body) != "page body" {
+		t.Fatalf("got %q after revalidation, want the cached body preserved", body)
+	}
+	if requests != 2 {
+		t.Fatalf("got %d requests, want 2 (initial fetch + revalidation)", requests)
+	}
+
+	entries, err := c.Cache.PageEntries(srv.URL)
+	if err != nil {
+		t.Fatalf("PageEntries: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d cache entries, want 2 (original + revalidation)", len(entries))
+	}
+}
+
+func TestFetchBodySnapshotDoesNotTouchNetwork(t *testing.T) {
+	url := "https://opensource.apple.com/"
+	c := NewClient(&failingDoer{t: t})
+	c.Cache = openTestCache(t)
+	if _, err := c.Cache.StorePage(url, []byte("snapshotted body"), "", "", time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("StorePage: %v", err)
+	}
+	snapshotAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.SnapshotAt = &snapshotAt
+
+	body, err := c.fetchBody(context.Background(), url)
+	if err != nil {
+		t.Fatalf("fetchBody: %v", err)
+	}
+	if string(body) != "snapshotted body" {
+		t.Fatalf("got %q, want the cached snapshot body", body)
+	}
+}
+
+// failingDoer fails the test if it is ever called, for asserting a code path
+// never falls back to the network.
+type failingDoer struct{ t *testing.T }
+
+func (f *failingDoer) Do(req *http.Request) (*http.Response, error) {
+	f.t.Helper()
+	f.t.Fatalf("unexpected network request to %s in snapshot mode", req.URL)
+	return nil, fmt.Errorf("unreachable")
+}
+
+func TestDownloadWritesBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tarball bytes"))
+	}))
+	defer srv.Close()
+
+	c := NewClient(http.DefaultClient)
+	var buf bytes.Buffer
+	pkg := Package{Name: "xnu", Version: "1234", URL: srv.URL}
+	if err := c.Download(context.Background(), pkg, &buf); err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	if buf.String() != "tarball bytes" {
+		t.Fatalf("got %q, want %q", buf.String(), "tarball bytes")
+	}
+}
+
+func TestDownloadNon200IsError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	c := NewClient(http.DefaultClient)
+	var buf bytes.Buffer
+	pkg := Package{Name: "xnu", Version: "1234", URL: srv.URL}
+	if err := c.Download(context.Background(), pkg, &buf); err == nil {
+		t.Fatal("expected an error for a non-200 response")
+	}
+}
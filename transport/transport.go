@@ -0,0 +1,106 @@
+// Package transport provides an http.RoundTripper that scrapes
+// opensource.apple.com politely: it rate-limits outgoing requests and
+// retries 5xx responses and connection errors with exponential backoff.
+package transport
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultRate is the default number of requests allowed per second.
+	DefaultRate = 1
+	// DefaultRetries is the default number of attempts made per request,
+	// including the first one.
+	DefaultRetries = 10
+	// DefaultUserAgent identifies osac to the origin server.
+	DefaultUserAgent = "osac/1.0 (+https://github.com/alexmullins/osac)"
+
+	baseBackoff = 500 * time.Millisecond
+	maxBackoff  = 30 * time.Second
+)
+
+// Transport wraps a base http.RoundTripper with rate limiting and retries.
+type Transport struct {
+	Base       http.RoundTripper
+	Limiter    *rate.Limiter
+	MaxRetries int
+	UserAgent  string
+}
+
+// New returns a Transport allowing requestsPerSecond requests/sec and
+// making up to maxRetries attempts per request, including the first one.
+// A requestsPerSecond <= 0
+// defaults to DefaultRate, and a maxRetries <= 0 defaults to
+// DefaultRetries.
+func New(requestsPerSecond float64, maxRetries int) *Transport {
+	if requestsPerSecond <= 0 {
+		requestsPerSecond = DefaultRate
+	}
+	if maxRetries <= 0 {
+		maxRetries = DefaultRetries
+	}
+	return &Transport{
+		Base:       http.DefaultTransport,
+		Limiter:    rate.NewLimiter(rate.Limit(requestsPerSecond), 1),
+		MaxRetries: maxRetries,
+		UserAgent:  DefaultUserAgent,
+	}
+}
+
+// RoundTrip rate-limits and retries req, honoring req's context for both
+// the rate limiter wait and cancellation between attempts.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < t.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			case <-time.After(backoff(attempt)):
+			}
+		}
+
+		if err := t.Limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+
+		attemptReq := req.Clone(req.Context())
+		if attemptReq.Header.Get("User-Agent") == "" {
+			attemptReq.Header.Set("User-Agent", t.UserAgent)
+		}
+
+		res, err := base.RoundTrip(attemptReq)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if res.StatusCode >= 500 && attempt < t.MaxRetries-1 {
+			res.Body.Close()
+			lastErr = fmt.Errorf("transport: got %d from %s", res.StatusCode, req.URL)
+			continue
+		}
+		return res, nil
+	}
+	return nil, fmt.Errorf("transport: %s failed after %d attempts: %w", req.URL, t.MaxRetries, lastErr)
+}
+
+// backoff returns the delay before the given retry attempt (1-indexed),
+// doubling each time and capped at maxBackoff.
+func backoff(attempt int) time.Duration {
+	d := time.Duration(float64(baseBackoff) * math.Pow(2, float64(attempt-1)))
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
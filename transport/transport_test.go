@@ -0,0 +1,144 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRoundTripRetriesOn5xx(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tr := New(1000, 5) // high rate so the limiter isn't what we're timing
+	client := &http.Client{Transport: tr}
+
+	res, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want 200", res.StatusCode)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Fatalf("got %d calls, want 3", got)
+	}
+}
+
+func TestRoundTripGivesUpAfterMaxRetries(t *testing.T) {
+	// A persistently failing server exhausts MaxRetries and the last
+	// attempt's response is returned as-is, rather than retried forever.
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	tr := New(1000, 2)
+	client := &http.Client{Transport: tr}
+
+	res, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got status %d, want %d", res.StatusCode, http.StatusServiceUnavailable)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("got %d calls, want 2", got)
+	}
+}
+
+func TestRoundTripGivesUpOnConnectionErrors(t *testing.T) {
+	// The address of a closed httptest server no longer accepts
+	// connections, simulating a host that's persistently unreachable
+	// rather than one returning 5xx.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close() // now nothing is listening on this address
+
+	tr := New(1000, 2)
+	client := &http.Client{Transport: tr}
+
+	if _, err := client.Get(url); err == nil {
+		t.Fatal("expected an error once the server is unreachable")
+	}
+}
+
+func TestRoundTripSetsUserAgent(t *testing.T) {
+	var gotUA string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUA = r.Header.Get("User-Agent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tr := New(1000, 1)
+	client := &http.Client{Transport: tr}
+	res, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	res.Body.Close()
+	if gotUA != DefaultUserAgent {
+		t.Fatalf("got User-Agent %q, want %q", gotUA, DefaultUserAgent)
+	}
+}
+
+func TestRoundTripDoesNotRetryOn2xx(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	tr := New(1000, 5)
+	client := &http.Client{Transport: tr}
+	res, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	res.Body.Close()
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("got %d calls, want 1", got)
+	}
+}
+
+func TestNewDefaults(t *testing.T) {
+	tr := New(0, 0)
+	if tr.MaxRetries != DefaultRetries {
+		t.Errorf("got MaxRetries %d, want %d", tr.MaxRetries, DefaultRetries)
+	}
+	if tr.Limiter.Limit() != rate.Limit(DefaultRate) {
+		t.Errorf("got rate %v, want %v", tr.Limiter.Limit(), DefaultRate)
+	}
+}
+
+func TestBackoffIsIncreasingAndCapped(t *testing.T) {
+	prev := time.Duration(0)
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := backoff(attempt)
+		if d < prev {
+			t.Fatalf("backoff(%d) = %v is less than backoff(%d) = %v", attempt, d, attempt-1, prev)
+		}
+		if d > maxBackoff {
+			t.Fatalf("backoff(%d) = %v exceeds maxBackoff %v", attempt, d, maxBackoff)
+		}
+		prev = d
+	}
+}